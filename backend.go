@@ -0,0 +1,150 @@
+package booster
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/nats-io/nats.go"
+)
+
+// Unsubscriber stops a subscription started by Backend.Subscribe.
+type Unsubscriber interface {
+	Unsubscribe() error
+}
+
+// Backend fans broadcast envelopes out across a cluster of Booster
+// processes, so PushMessage reaches a user connected to a different node.
+//
+// Publish sends env to every other node subscribed for appId. Subscribe
+// registers deliver to be called whenever another node publishes for appId;
+// it must be safe to call deliver from the Backend's own goroutine.
+type Backend interface {
+	Publish(appId string, env *envelope) error
+	Subscribe(appId string, deliver func(*envelope)) (Unsubscriber, error)
+}
+
+// wireEnvelope is the on-the-wire shape of an envelope. The filter func and
+// the local flag only make sense inside the process that built it, so they
+// are not carried across the backend: a message pushed with a filter is
+// delivered unfiltered to matching userIds/rooms on every other node.
+type wireEnvelope struct {
+	Type    int      `json:"type"`
+	Msg     []byte   `json:"msg"`
+	UserIds []string `json:"userIds,omitempty"`
+	Rooms   []string `json:"rooms,omitempty"`
+}
+
+func encodeEnvelope(env *envelope) ([]byte, error) {
+	return json.Marshal(&wireEnvelope{
+		Type:    env.t,
+		Msg:     env.msg,
+		UserIds: env.userIds,
+		Rooms:   env.rooms,
+	})
+}
+
+func decodeEnvelope(data []byte) (*envelope, error) {
+	var w wireEnvelope
+	if err := json.Unmarshal(data, &w); err != nil {
+		return nil, err
+	}
+
+	return &envelope{t: w.Type, msg: w.Msg, userIds: w.UserIds, rooms: w.Rooms, local: true}, nil
+}
+
+func backendSubject(appId string) string {
+	return "booster." + appId
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// NatsBackend fans envelopes out through an already-connected NATS conn.
+type NatsBackend struct {
+	nc *nats.Conn
+}
+
+// NewNatsBackend wraps nc as a Backend.
+func NewNatsBackend(nc *nats.Conn) *NatsBackend {
+	return &NatsBackend{nc: nc}
+}
+
+func (b *NatsBackend) Publish(appId string, env *envelope) error {
+	data, err := encodeEnvelope(env)
+	if err != nil {
+		return err
+	}
+
+	if err := b.nc.Publish(backendSubject(appId), data); err != nil {
+		return err
+	}
+
+	// Flush blocks until the server has processed everything published so
+	// far, so an error here means env may not have reached the server.
+	// Delivery to subscribers is not confirmed and nothing is retried.
+	return b.nc.Flush()
+}
+
+func (b *NatsBackend) Subscribe(appId string, deliver func(*envelope)) (Unsubscriber, error) {
+	return b.nc.Subscribe(backendSubject(appId), func(msg *nats.Msg) {
+		env, err := decodeEnvelope(msg.Data)
+		if err != nil {
+			return
+		}
+
+		deliver(env)
+	})
+}
+
+// ---------------------------------------------------------------------------------------------------------------------
+
+// RedisBackend fans envelopes out through an already-connected redis client,
+// using a Pub/Sub channel per appId.
+type RedisBackend struct {
+	rdb *redis.Client
+	ctx context.Context
+}
+
+// NewRedisBackend wraps rdb as a Backend.
+func NewRedisBackend(rdb *redis.Client) *RedisBackend {
+	return &RedisBackend{rdb: rdb, ctx: context.Background()}
+}
+
+func (b *RedisBackend) Publish(appId string, env *envelope) error {
+	data, err := encodeEnvelope(env)
+	if err != nil {
+		return err
+	}
+
+	// Only the command's error is checked: the subscriber count PUBLISH
+	// replies with is not looked at, and nothing is retried.
+	return b.rdb.Publish(b.ctx, backendSubject(appId), data).Err()
+}
+
+type redisUnsubscriber struct {
+	sub *redis.PubSub
+}
+
+func (u *redisUnsubscriber) Unsubscribe() error {
+	return u.sub.Close()
+}
+
+func (b *RedisBackend) Subscribe(appId string, deliver func(*envelope)) (Unsubscriber, error) {
+	sub := b.rdb.Subscribe(b.ctx, backendSubject(appId))
+	if _, err := sub.Receive(b.ctx); err != nil {
+		return nil, err
+	}
+
+	go func() {
+		for msg := range sub.Channel() {
+			env, err := decodeEnvelope([]byte(msg.Payload))
+			if err != nil {
+				continue
+			}
+
+			deliver(env)
+		}
+	}()
+
+	return &redisUnsubscriber{sub: sub}, nil
+}
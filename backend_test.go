@@ -0,0 +1,47 @@
+package booster
+
+import "testing"
+
+func TestEncodeDecodeEnvelope_RoundTrip(t *testing.T) {
+	env := &envelope{
+		t:       TextMessage,
+		msg:     []byte("hello"),
+		userIds: []string{"u1", "u2"},
+		rooms:   []string{"r1"},
+	}
+
+	data, err := encodeEnvelope(env)
+	if err != nil {
+		t.Fatalf("encodeEnvelope: %v", err)
+	}
+
+	got, err := decodeEnvelope(data)
+	if err != nil {
+		t.Fatalf("decodeEnvelope: %v", err)
+	}
+
+	if got.t != env.t || string(got.msg) != string(env.msg) {
+		t.Fatalf("decodeEnvelope = %+v, want t/msg matching %+v", got, env)
+	}
+	if len(got.userIds) != 2 || got.userIds[0] != "u1" || got.userIds[1] != "u2" {
+		t.Fatalf("decodeEnvelope userIds = %v, want [u1 u2]", got.userIds)
+	}
+	if len(got.rooms) != 1 || got.rooms[0] != "r1" {
+		t.Fatalf("decodeEnvelope rooms = %v, want [r1]", got.rooms)
+	}
+
+	// filter and local are process-local only: a decoded envelope is always
+	// treated as having come from the backend, never re-published.
+	if !got.local {
+		t.Fatalf("decodeEnvelope local = false, want true")
+	}
+	if got.filter != nil {
+		t.Fatalf("decodeEnvelope filter = %v, want nil", got.filter)
+	}
+}
+
+func TestDecodeEnvelope_MalformedJSON(t *testing.T) {
+	if _, err := decodeEnvelope([]byte("not json")); err == nil {
+		t.Fatalf("decodeEnvelope with malformed JSON: got nil error, want non-nil")
+	}
+}
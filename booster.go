@@ -1,6 +1,7 @@
 package booster
 
 import (
+	"context"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
@@ -9,11 +10,11 @@ import (
 	"sync"
 )
 
-type handleMessageFunc func(*Session, []byte)
-type handleErrorFunc func(*Session, error)
-type handleCloseFunc func(*Session, int, string) error
-type handleSessionFunc func(*Session)
-type FilterFunc func(*Session) bool
+type handleMessageFunc func(Session, []byte)
+type handleErrorFunc func(Session, error)
+type handleCloseFunc func(Session, int, string) error
+type handleSessionFunc func(Session)
+type FilterFunc func(Session) bool
 
 const (
 	CloseNormalClosure           = 1000
@@ -58,63 +59,220 @@ type Booster struct {
 	connectHandler    handleSessionFunc
 	disconnectHandler handleSessionFunc
 	hubs              map[string]*Hub
+	backend           Backend
+	router            *Router
 
 	sync.RWMutex
 }
 
-func NewBooster() *Booster {
+// Option configures a Booster at construction time.
+type Option func(*Booster)
+
+// WithBackend wires a cluster-wide Backend into the Booster, so PushMessage
+// reaches sessions registered on other nodes, not just this process.
+func WithBackend(backend Backend) Option {
+	return func(b *Booster) {
+		b.backend = backend
+	}
+}
+
+// WithCodec overrides the Codec used by On/WriteTyped, JSONCodec by default.
+func WithCodec(codec Codec) Option {
+	return func(b *Booster) {
+		b.router.codec = codec
+	}
+}
+
+func NewBooster(opts ...Option) *Booster {
 	upGrader := &websocket.Upgrader{
 		ReadBufferSize:  1024,
 		WriteBufferSize: 1024,
 		CheckOrigin:     func(r *http.Request) bool { return true },
 	}
 
-	return &Booster{
+	b := &Booster{
 		config:   newConfig(),
 		upGrader: upGrader,
 		hubs:     make(map[string]*Hub),
 	}
+	b.router = newRouter(b, JSONCodec{})
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
 }
 
 // HandleMessage called when data is received from peer.
-func (b *Booster) HandleMessage(fn func(*Session, []byte)) {
+func (b *Booster) HandleMessage(fn func(Session, []byte)) {
 	b.messageHandler = fn
 }
 
 // HandleError called when any error happen
-func (b *Booster) HandleError(fn func(*Session, error)) {
+func (b *Booster) HandleError(fn func(Session, error)) {
 	b.errorHandler = fn
 }
 
 // HandleClose called when peer close the conn
-func (b *Booster) HandleClose(fn func(*Session, int, string) error) {
+func (b *Booster) HandleClose(fn func(Session, int, string) error) {
 	b.closeHandler = fn
 }
 
 // HandleConnect called when peer connect
-func (b *Booster) HandleConnect(fn func(*Session)) {
+func (b *Booster) HandleConnect(fn func(Session)) {
 	b.connectHandler = fn
 }
 
 // HandleDisConnect called when peer disconnect
-func (b *Booster) HandleDisConnect(fn func(*Session)) {
+func (b *Booster) HandleDisConnect(fn func(Session)) {
 	b.disconnectHandler = fn
 }
 
+// On registers a typed handler for messages tagged name, dispatched by
+// Router. fn must be func(Session, *T) error, where T is unmarshaled
+// by the Booster's Codec (JSONCodec unless WithCodec was used). The first
+// call to On takes over HandleMessage, since a connection dispatches either
+// raw messages or typed ones, not both.
+func (b *Booster) On(name string, fn interface{}) {
+	b.router.on(name, fn)
+	b.messageHandler = b.router.handle
+}
+
 // PushMessage push the msg to user.
 // fn is a filter function, and will called before msg send,
-// if fn return false, the msg will not send
-func (b *Booster) PushMessage(appId string, userIds []string, msg []byte, fn func(*Session) bool) error {
+// if fn return false, the msg will not send.
+// fn only runs against sessions registered on this node: when a Backend is
+// configured, other nodes deliver to every matching userId unfiltered.
+func (b *Booster) PushMessage(appId string, userIds []string, msg []byte, fn func(Session) bool) error {
 	h := b.getHub(appId)
 	if h.Closed() {
 		return fmt.Errorf("appId[%v] hub has closed", appId)
 	}
 
-	message := &envelope{t: websocket.TextMessage, userIds: userIds, msg: msg, filter: fn}
+	message := &envelope{t: TextMessage, userIds: userIds, msg: msg, filter: fn}
 	h.broadcast <- message
+
+	if b.backend != nil && !message.local {
+		if err := b.backend.Publish(appId, message); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
+// PushToRoom push the msg to every session that has joined one of rooms.
+// fn is a filter function, and will called before msg send,
+// if fn return false, the msg will not send.
+// fn only runs against sessions registered on this node: when a Backend is
+// configured, other nodes deliver to every matching room unfiltered.
+func (b *Booster) PushToRoom(appId string, rooms []string, msg []byte, fn FilterFunc) error {
+	h := b.getHub(appId)
+	if h.Closed() {
+		return fmt.Errorf("appId[%v] hub has closed", appId)
+	}
+
+	message := &envelope{t: TextMessage, rooms: rooms, msg: msg, filter: fn}
+	h.broadcast <- message
+
+	if b.backend != nil && !message.local {
+		if err := b.backend.Publish(appId, message); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// IsOnline reports whether userId has at least one session registered
+// under appId.
+func (b *Booster) IsOnline(appId, userId string) bool {
+	h := b.getHub(appId)
+	if h.Closed() {
+		return false
+	}
+
+	online := false
+	h.runQuery(func(h *Hub) {
+		online = len(h.sessions[userId]) > 0
+	})
+	return online
+}
+
+// SessionCount returns how many sessions are currently registered under
+// appId, across every userId.
+func (b *Booster) SessionCount(appId string) int {
+	h := b.getHub(appId)
+	if h.Closed() {
+		return 0
+	}
+
+	count := 0
+	h.runQuery(func(h *Hub) {
+		for _, ss := range h.sessions {
+			count += len(ss)
+		}
+	})
+	return count
+}
+
+// ForEachSession calls fn for every session currently registered under
+// appId, stopping early if fn returns false.
+func (b *Booster) ForEachSession(appId string, fn func(Session) bool) {
+	h := b.getHub(appId)
+	if h.Closed() {
+		return
+	}
+
+	h.runQuery(func(h *Hub) {
+		for _, ss := range h.sessions {
+			for _, s := range ss {
+				if !fn(s) {
+					return
+				}
+			}
+		}
+	})
+}
+
+// WaitForSession blocks until userId registers a session under appId, or
+// ctx is done, whichever happens first. If ctx is done first, the waiter
+// is pruned from the hub so it cannot leak when the user never connects.
+func (b *Booster) WaitForSession(ctx context.Context, appId, userId string) (Session, error) {
+	h := b.getHub(appId)
+	if h.Closed() {
+		return nil, fmt.Errorf("appId[%v] hub has closed", appId)
+	}
+
+	op := &waitOp{userId: userId, result: make(chan Session, 1)}
+	h.waitFor <- op
+
+	select {
+	case s, ok := <-op.result:
+		if !ok {
+			return nil, fmt.Errorf("appId[%v] hub has closed", appId)
+		}
+		return s, nil
+
+	case <-ctx.Done():
+		h.cancelWait <- op
+
+		// A session may have registered in the instant between ctx firing
+		// and the cancel landing; op.result is buffered, so take it if so
+		// instead of discarding a peer that just connected.
+		select {
+		case s, ok := <-op.result:
+			if ok {
+				return s, nil
+			}
+		default:
+		}
+
+		return nil, ctx.Err()
+	}
+}
+
 // HandleWs is the access interface, it can be register to gin's router for use
 //
 // for example:
@@ -145,16 +303,7 @@ func (b *Booster) HandleWs(c *gin.Context) {
 		return
 	}
 
-	session := &Session{
-		conn:    conn,
-		output:  make(chan *envelope, b.config.MessageBufferSize),
-		booster: b,
-		appId:   appId,
-		userId:  userId,
-		keys:    make(map[string]interface{}),
-		params:  h.GetParams(),
-		exited:  make(chan bool),
-	}
+	session := newWsSession(b, conn, appId, userId, h.GetParams())
 
 	if b.connectHandler != nil {
 		b.connectHandler(session)
@@ -162,6 +311,7 @@ func (b *Booster) HandleWs(c *gin.Context) {
 
 	hub.register <- session
 
+	go session.worker()
 	go session.writePump()
 	session.readPump()
 
@@ -172,6 +322,61 @@ func (b *Booster) HandleWs(c *gin.Context) {
 	}
 }
 
+// HandleSSE is a one-way fallback for HandleWs: it serves a text/event-stream
+// connection but registers into the same Hub under the same appId/userId,
+// so PushMessage reaches SSE and websocket peers uniformly. It has no read
+// pump, so HandleMessage/On never fire for an SSE peer.
+//
+// for example:
+// engine := gin.New()
+// engine.Get("/events", booster.GetInstance().HandleSSE)
+//
+func (b *Booster) HandleSSE(c *gin.Context) {
+	var (
+		h      = NewHelper(c)
+		appId  = h.StringParam("appId")
+		userId = h.StringParam("userId")
+	)
+
+	if appId == "" || userId == "" {
+		log.Errorf("[HandleSSE] appId[%v], userId[%v] [invalid params]", appId, userId)
+		return
+	}
+
+	hub := b.getHub(appId)
+	if hub.Closed() {
+		log.Error("[HandleSSE] appId[%v], userId[%v] [hub already closed]", appId, userId)
+		return
+	}
+
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		log.Errorf("[HandleSSE] appId[%v], userId[%v] [streaming unsupported]", appId, userId)
+		return
+	}
+
+	c.Writer.Header().Set("Content-Type", "text/event-stream")
+	c.Writer.Header().Set("Cache-Control", "no-cache")
+	c.Writer.Header().Set("Connection", "keep-alive")
+
+	session := newSseSession(b, c.Writer, flusher, c.Request.Context(), appId, userId, h.GetParams())
+
+	if b.connectHandler != nil {
+		b.connectHandler(session)
+	}
+
+	hub.register <- session
+
+	go session.worker()
+	session.writePump()
+
+	hub.unRegister <- session
+
+	if b.disconnectHandler != nil {
+		b.disconnectHandler(session)
+	}
+}
+
 // CloseBooster release booster instance
 func (b *Booster) CloseBooster() {
 	for _, hub := range b.hubs {
@@ -185,6 +390,11 @@ func (b *Booster) getHub(appId string) *Hub {
 		b.RUnlock()
 
 		h := NewHub()
+		if b.backend != nil {
+			if err := h.attachBackend(b.backend, appId); err != nil {
+				log.Errorf("[getHub] appId[%v] [attach backend fail, %v]", appId, err.Error())
+			}
+		}
 		go h.Run()
 
 		b.Lock()
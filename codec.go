@@ -0,0 +1,52 @@
+package booster
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/proto"
+)
+
+// Codec marshals/unmarshals the payload carried inside a typed message
+// frame. Marshal reports the websocket frame type (TextMessage or
+// BinaryMessage) the marshaled payload should be sent as.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec marshals payloads as JSON text frames.
+type JSONCodec struct{}
+
+func (JSONCodec) Marshal(v interface{}) ([]byte, byte, error) {
+	data, err := json.Marshal(v)
+	return data, websocket.TextMessage, err
+}
+
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// ProtoCodec marshals payloads as protobuf binary frames. v must implement
+// proto.Message.
+type ProtoCodec struct{}
+
+func (ProtoCodec) Marshal(v interface{}) ([]byte, byte, error) {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return nil, 0, fmt.Errorf("booster: %T does not implement proto.Message", v)
+	}
+
+	data, err := proto.Marshal(msg)
+	return data, websocket.BinaryMessage, err
+}
+
+func (ProtoCodec) Unmarshal(data []byte, v interface{}) error {
+	msg, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("booster: %T does not implement proto.Message", v)
+	}
+
+	return proto.Unmarshal(data, msg)
+}
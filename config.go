@@ -0,0 +1,72 @@
+package booster
+
+import (
+	"time"
+)
+
+// Config holds the tunables shared by every Hub/Session the Booster manages.
+type Config struct {
+	// MessageBufferSize is the size of a Session's outbound buffered channel.
+	MessageBufferSize int
+
+	// WriteWait is the deadline for a single write to the peer.
+	WriteWait time.Duration
+
+	// PongWait is how long we wait for a pong before considering the peer dead.
+	PongWait time.Duration
+
+	// PingPeriod is how often we send pings to the peer, must be less than PongWait.
+	PingPeriod time.Duration
+
+	// MaxMessageSize is the maximum message size allowed from peer.
+	MaxMessageSize int64
+
+	// BackpressurePolicy controls what a Session does when its output
+	// buffer is full, DropNewest by default.
+	BackpressurePolicy BackpressurePolicy
+
+	// WriteEnqueueTimeout bounds how long BlockWithTimeout waits for room
+	// in the output buffer before dropping the message.
+	WriteEnqueueTimeout time.Duration
+
+	// MaxConsecutiveDrops is how many consecutive full-buffer events
+	// CloseSlow tolerates before closing and unregistering the session.
+	MaxConsecutiveDrops int
+}
+
+// BackpressurePolicy controls what happens when a Session's output buffer
+// is full.
+type BackpressurePolicy int
+
+const (
+	// DropNewest discards the message that didn't fit, keeping whatever is
+	// already buffered. This is the original, default behavior.
+	DropNewest BackpressurePolicy = iota
+
+	// DropOldest evicts the head of the buffer to make room for the
+	// message that didn't fit.
+	DropOldest
+
+	// BlockWithTimeout waits up to Config.WriteEnqueueTimeout for room in
+	// the buffer before dropping the message.
+	BlockWithTimeout
+
+	// CloseSlow sends a close frame and unregisters the session once its
+	// buffer has been full for Config.MaxConsecutiveDrops messages in a row.
+	CloseSlow
+)
+
+func newConfig() *Config {
+	pongWait := 60 * time.Second
+
+	return &Config{
+		MessageBufferSize:   256,
+		WriteWait:           10 * time.Second,
+		PongWait:            pongWait,
+		PingPeriod:          (pongWait * 9) / 10,
+		MaxMessageSize:      512,
+		BackpressurePolicy:  DropNewest,
+		WriteEnqueueTimeout: 1 * time.Second,
+		MaxConsecutiveDrops: 5,
+	}
+}
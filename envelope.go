@@ -0,0 +1,32 @@
+package booster
+
+// Message type tags carried on envelope.t. The values mirror gorilla/
+// websocket's own frame type constants (RFC 6455 opcodes) so a wsSession
+// can pass t straight through to conn.WriteMessage; other transports
+// interpret them as a transport-agnostic "kind" of frame instead.
+const (
+	TextMessage   = 1
+	BinaryMessage = 2
+	CloseMessage  = 8
+	PingMessage   = 9
+)
+
+// envelope is the unit dispatched through a Hub, to a Session's output
+// channel and, when a Backend is configured, across the cluster.
+type envelope struct {
+	t   int
+	msg []byte
+
+	userIds []string
+	rooms   []string
+
+	// filter is evaluated only against sessions registered on this node:
+	// it cannot be serialized, so a Backend delivers to every matching
+	// userId/room on other nodes unfiltered. Callers that need cluster-wide
+	// filtering must encode the decision in msg itself and filter client-side.
+	filter FilterFunc
+
+	// local marks an envelope that was just delivered by a Backend, so it is
+	// not handed back to the Backend and echoed forever.
+	local bool
+}
@@ -4,28 +4,89 @@ import (
 	"sync/atomic"
 )
 
+// roomOp is a Join/Leave request handed to Hub.Run over joinRoom/leaveRoom.
+type roomOp struct {
+	session Session
+	room    string
+}
+
+// hubQuery is a snapshot read handed to Hub.Run over the query channel. fn
+// runs on Run's own goroutine, so it can read sessions/rooms race-free.
+type hubQuery struct {
+	fn   func(h *Hub)
+	done chan struct{}
+}
+
+// waitOp is a WaitForSession request handed to Hub.Run over waitFor. result
+// receives the session as soon as userId registers.
+type waitOp struct {
+	userId string
+	result chan Session
+}
+
 type Hub struct {
 	broadcast  chan *envelope
-	register   chan *Session
-	unRegister chan *Session
+	backendIn  chan *envelope
+	register   chan Session
+	unRegister chan Session
+	joinRoom   chan *roomOp
+	leaveRoom  chan *roomOp
+	query      chan *hubQuery
+	waitFor    chan *waitOp
+	cancelWait chan *waitOp
 
 	closed uint32
 	exit   chan bool
 	exited chan bool
 
-	sessions map[string][]*Session
+	sessions map[string][]Session
+	rooms    map[string]map[Session]struct{}
+	waiters  map[string][]chan Session
+
+	unsub Unsubscriber
 }
 
 func NewHub() *Hub {
 	return &Hub{
 		broadcast:  make(chan *envelope),
-		register:   make(chan *Session),
-		unRegister: make(chan *Session),
+		backendIn:  make(chan *envelope),
+		register:   make(chan Session),
+		unRegister: make(chan Session),
+		joinRoom:   make(chan *roomOp),
+		leaveRoom:  make(chan *roomOp),
+		query:      make(chan *hubQuery),
+		waitFor:    make(chan *waitOp),
+		cancelWait: make(chan *waitOp),
 		exit:       make(chan bool),
 		exited:     make(chan bool),
 
-		sessions: make(map[string][]*Session),
+		sessions: make(map[string][]Session),
+		rooms:    make(map[string]map[Session]struct{}),
+		waiters:  make(map[string][]chan Session),
+	}
+}
+
+// runQuery runs fn on Hub.Run's own goroutine and waits for it to finish,
+// giving fn a race-free snapshot of sessions/rooms to read.
+func (h *Hub) runQuery(fn func(h *Hub)) {
+	q := &hubQuery{fn: fn, done: make(chan struct{})}
+	h.query <- q
+	<-q.done
+}
+
+// attachBackend wires the hub up to a cluster-wide Backend, so envelopes
+// published from any other node for appId are fanned out to this hub's
+// locally-registered sessions exactly like a local broadcast.
+func (h *Hub) attachBackend(backend Backend, appId string) error {
+	unsub, err := backend.Subscribe(appId, func(env *envelope) {
+		h.backendIn <- env
+	})
+	if err != nil {
+		return err
 	}
+
+	h.unsub = unsub
+	return nil
 }
 
 func (h *Hub) Run() {
@@ -35,15 +96,28 @@ LOOP:
 		case <-h.exit:
 			atomic.StoreUint32(&h.closed, 1)
 
+			if h.unsub != nil {
+				h.unsub.Unsubscribe()
+			}
+
 			for key, ss := range h.sessions {
 				for _, s := range ss {
 					s.Close()
-					close(s.output)
-					<-s.exited
+					s.closeSendQueue()
+					s.waitWorkerExited()
+					s.closeOutput()
+					s.waitExited()
 				}
 				delete(h.sessions, key)
 			}
 
+			for userId, chans := range h.waiters {
+				for _, ch := range chans {
+					close(ch)
+				}
+				delete(h.waiters, userId)
+			}
+
 			break LOOP
 
 		case s := <-h.register:
@@ -51,62 +125,178 @@ LOOP:
 				break
 			}
 
-			if _, ok := h.sessions[s.userId]; !ok {
-				h.sessions[s.userId] = make([]*Session, 0)
+			userId := s.GetUserId()
+			if _, ok := h.sessions[userId]; !ok {
+				h.sessions[userId] = make([]Session, 0)
 			}
 
-			h.sessions[s.userId] = append(h.sessions[s.userId], s)
+			h.sessions[userId] = append(h.sessions[userId], s)
+
+			if chans, ok := h.waiters[userId]; ok {
+				for _, ch := range chans {
+					ch <- s
+				}
+				delete(h.waiters, userId)
+			}
 
 		case s := <-h.unRegister:
 			if h.Closed() {
 				break
 			}
 
-			if ss, ok := h.sessions[s.userId]; ok {
+			userId := s.GetUserId()
+			if ss, ok := h.sessions[userId]; ok {
 				for i, s1 := range ss {
 					if s1 == s {
-						h.sessions[s.userId] = append(h.sessions[s.userId][:i], h.sessions[s.userId][i+1:]...)
+						h.sessions[userId] = append(h.sessions[userId][:i], h.sessions[userId][i+1:]...)
 						break
 					}
 				}
 			}
 
-		case e := <-h.broadcast:
+			for room, ss := range h.rooms {
+				if _, ok := ss[s]; !ok {
+					continue
+				}
+				delete(ss, s)
+				if len(ss) == 0 {
+					delete(h.rooms, room)
+				}
+			}
+
+		case op := <-h.joinRoom:
 			if h.Closed() {
 				break
 			}
 
-			if len(e.userIds) > 0 {
-				for _, userId := range e.userIds {
-					ss, ok := h.sessions[userId]
-					if !ok {
-						continue
-					}
-					for _, s := range ss {
-						if e.filter != nil && !e.filter(s) {
-							continue
-						}
-						s.output <- e
-					}
+			if _, ok := h.rooms[op.room]; !ok {
+				h.rooms[op.room] = make(map[Session]struct{})
+			}
+			h.rooms[op.room][op.session] = struct{}{}
+
+		case op := <-h.leaveRoom:
+			if h.Closed() {
+				break
+			}
+
+			if ss, ok := h.rooms[op.room]; ok {
+				delete(ss, op.session)
+				if len(ss) == 0 {
+					delete(h.rooms, op.room)
 				}
+			}
+
+		case e := <-h.broadcast:
+			if h.Closed() {
 				break
 			}
 
-			for _, ss := range h.sessions {
-				for _, s := range ss {
-					if e.filter != nil && !e.filter(s) {
-						continue
-					}
-					s.output <- e
+			h.dispatch(e)
+
+		case e := <-h.backendIn:
+			if h.Closed() {
+				break
+			}
+
+			h.dispatch(e)
+
+		case q := <-h.query:
+			q.fn(h)
+			close(q.done)
+
+		case op := <-h.waitFor:
+			if h.Closed() {
+				close(op.result)
+				break
+			}
+
+			if ss, ok := h.sessions[op.userId]; ok && len(ss) > 0 {
+				op.result <- ss[0]
+				break
+			}
+
+			h.waiters[op.userId] = append(h.waiters[op.userId], op.result)
+
+		case op := <-h.cancelWait:
+			chans, ok := h.waiters[op.userId]
+			if !ok {
+				break
+			}
+
+			for i, ch := range chans {
+				if ch == op.result {
+					h.waiters[op.userId] = append(chans[:i], chans[i+1:]...)
+					break
 				}
 			}
 
+			if len(h.waiters[op.userId]) == 0 {
+				delete(h.waiters, op.userId)
+			}
+
 		}
 	}
 
 	h.exited <- true
 }
 
+// dispatch fans e out to every locally-registered session it targets,
+// regardless of whether e came from a local broadcast or a Backend delivery.
+func (h *Hub) dispatch(e *envelope) {
+	if len(e.rooms) > 0 {
+		sent := make(map[Session]struct{})
+		for _, room := range e.rooms {
+			for s := range h.rooms[room] {
+				if _, ok := sent[s]; ok {
+					continue
+				}
+				sent[s] = struct{}{}
+
+				if e.filter != nil && !e.filter(s) {
+					continue
+				}
+				h.send(s, e)
+			}
+		}
+		return
+	}
+
+	if len(e.userIds) > 0 {
+		for _, userId := range e.userIds {
+			ss, ok := h.sessions[userId]
+			if !ok {
+				continue
+			}
+			for _, s := range ss {
+				if e.filter != nil && !e.filter(s) {
+					continue
+				}
+				h.send(s, e)
+			}
+		}
+		return
+	}
+
+	for _, ss := range h.sessions {
+		for _, s := range ss {
+			if e.filter != nil && !e.filter(s) {
+				continue
+			}
+			h.send(s, e)
+		}
+	}
+}
+
+// send hands e to s's own worker instead of applying s's BackpressurePolicy
+// here: enqueue/closeSlow can block (BlockWithTimeout) or write straight to
+// the peer (CloseSlow), and running them inline, or in a fresh goroutine
+// per envelope, let one dead or slow peer stall Run or reorder what that
+// peer receives. submit only ever queues, never blocks, and s's single
+// worker goroutine preserves the order Run dispatched in.
+func (h *Hub) send(s Session, e *envelope) {
+	s.submit(e)
+}
+
 func (h *Hub) Close() {
 	close(h.exit)
 	<-h.exited
@@ -0,0 +1,142 @@
+package booster
+
+import (
+	"testing"
+	"time"
+)
+
+// TestHub_SlowSessionDoesNotBlockRun reproduces the bug where a session
+// stuck behind BlockWithTimeout, dispatched inline, stalled Run's own
+// goroutine (and so every other hub operation) for up to WriteEnqueueTimeout.
+func TestHub_SlowSessionDoesNotBlockRun(t *testing.T) {
+	slowBooster := NewBooster()
+	slowBooster.config.BackpressurePolicy = BlockWithTimeout
+	slowBooster.config.WriteEnqueueTimeout = 200 * time.Millisecond
+
+	h := NewHub()
+	go h.Run()
+	defer h.Close()
+
+	slow := newTestSession(slowBooster, 1)
+	slow.userId = "slow"
+	t.Cleanup(func() { slow.closeSendQueue() })
+	h.register <- slow
+	defer func() { h.unRegister <- slow }()
+
+	// Fill slow's only buffer slot.
+	h.broadcast <- &envelope{t: TextMessage, userIds: []string{"slow"}, msg: []byte("1")}
+	time.Sleep(20 * time.Millisecond)
+
+	// A second broadcast to the still-full session would have to block for
+	// WriteEnqueueTimeout if handled on Run's own goroutine.
+	h.broadcast <- &envelope{t: TextMessage, userIds: []string{"slow"}, msg: []byte("2")}
+
+	start := time.Now()
+	h.runQuery(func(h *Hub) {})
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Fatalf("runQuery took %v, Hub.Run appears blocked on a slow session", elapsed)
+	}
+}
+
+// TestHub_PreservesSendOrder reproduces the bug where dispatching each
+// envelope in its own fire-and-forget goroutine let sends to the same
+// session race each other and arrive out of order.
+func TestHub_PreservesSendOrder(t *testing.T) {
+	b := NewBooster()
+
+	h := NewHub()
+	go h.Run()
+	defer h.Close()
+
+	s := newTestSession(b, 256)
+	s.userId = "user"
+	t.Cleanup(func() { s.closeSendQueue() })
+	h.register <- s
+	defer func() { h.unRegister <- s }()
+
+	const n = 200
+	for i := 0; i < n; i++ {
+		h.broadcast <- &envelope{t: TextMessage, userIds: []string{"user"}, msg: []byte{byte(i)}}
+	}
+
+	for i := 0; i < n; i++ {
+		select {
+		case msg := <-s.output:
+			if got := int(msg.msg[0]); got != i {
+				t.Fatalf("message %d arrived out of order: got %d", i, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for message %d", i)
+		}
+	}
+}
+
+// TestHub_RoomMembership covers Join/Leave bookkeeping: a session reaches
+// h.rooms[room] on join, PushToRoom-style dispatch reaches it there, and
+// the room drops out of h.rooms once its only member leaves.
+func TestHub_RoomMembership(t *testing.T) {
+	b := NewBooster()
+
+	h := NewHub()
+	go h.Run()
+	defer h.Close()
+
+	s := newTestSession(b, 8)
+	s.userId = "user"
+	t.Cleanup(func() { s.closeSendQueue() })
+	h.register <- s
+	defer func() { h.unRegister <- s }()
+
+	h.joinRoom <- &roomOp{session: s, room: "lobby"}
+
+	var inRoom bool
+	h.runQuery(func(h *Hub) {
+		_, inRoom = h.rooms["lobby"][s]
+	})
+	if !inRoom {
+		t.Fatalf("session not found in h.rooms[\"lobby\"] after joinRoom")
+	}
+
+	h.broadcast <- &envelope{t: TextMessage, rooms: []string{"lobby"}, msg: []byte("hi")}
+	select {
+	case msg := <-s.output:
+		if string(msg.msg) != "hi" {
+			t.Fatalf("room broadcast delivered %q, want %q", msg.msg, "hi")
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for room broadcast")
+	}
+
+	h.leaveRoom <- &roomOp{session: s, room: "lobby"}
+
+	var roomExists bool
+	h.runQuery(func(h *Hub) {
+		_, roomExists = h.rooms["lobby"]
+	})
+	if roomExists {
+		t.Fatalf("h.rooms[\"lobby\"] still exists after its only member left")
+	}
+}
+
+// TestHub_CancelWaitPrunesWaiter reproduces the bug where a WaitForSession
+// call that timed out before its user ever connected left its result
+// channel parked in h.waiters forever.
+func TestHub_CancelWaitPrunesWaiter(t *testing.T) {
+	h := NewHub()
+	go h.Run()
+	defer h.Close()
+
+	op := &waitOp{userId: "never-connects", result: make(chan Session, 1)}
+	h.waitFor <- op
+
+	h.cancelWait <- op
+
+	var waiterCount int
+	h.runQuery(func(h *Hub) {
+		waiterCount = len(h.waiters["never-connects"])
+	})
+
+	if waiterCount != 0 {
+		t.Fatalf("waiters[\"never-connects\"] has %d entries after cancel, want 0", waiterCount)
+	}
+}
@@ -0,0 +1,108 @@
+package booster
+
+import (
+	"encoding/binary"
+	"fmt"
+	"reflect"
+)
+
+// encodeFrame builds the wire format shared by every Codec: a varint length
+// prefix for name, followed by name, followed by the already-marshaled
+// payload.
+func encodeFrame(name string, payload []byte) []byte {
+	header := make([]byte, binary.MaxVarintLen64)
+	n := binary.PutUvarint(header, uint64(len(name)))
+
+	frame := make([]byte, 0, n+len(name)+len(payload))
+	frame = append(frame, header[:n]...)
+	frame = append(frame, name...)
+	frame = append(frame, payload...)
+	return frame
+}
+
+// decodeFrame splits a frame built by encodeFrame back into its name and
+// payload.
+func decodeFrame(data []byte) (name string, payload []byte, err error) {
+	nameLen, n := binary.Uvarint(data)
+	if n <= 0 {
+		return "", nil, fmt.Errorf("booster: malformed frame header")
+	}
+
+	if uint64(len(data)-n) < nameLen {
+		return "", nil, fmt.Errorf("booster: truncated frame")
+	}
+
+	name = string(data[n : n+int(nameLen)])
+	payload = data[n+int(nameLen):]
+	return name, payload, nil
+}
+
+// route holds what On registered for a single message name.
+type route struct {
+	msgType reflect.Type
+	call    func(s Session, msg reflect.Value) error
+}
+
+// Router dispatches incoming typed messages, framed by encodeFrame, to the
+// handler registered for their name.
+type Router struct {
+	booster *Booster
+	codec   Codec
+	routes  map[string]*route
+}
+
+func newRouter(b *Booster, codec Codec) *Router {
+	return &Router{booster: b, codec: codec, routes: make(map[string]*route)}
+}
+
+// on registers fn, which must be func(Session, *T) error for some type T
+// the Router's codec can unmarshal into, as the handler for name.
+func (r *Router) on(name string, fn interface{}) {
+	fv := reflect.ValueOf(fn)
+	ft := fv.Type()
+
+	if ft.Kind() != reflect.Func || ft.NumIn() != 2 || ft.NumOut() != 1 || ft.In(1).Kind() != reflect.Ptr {
+		panic(fmt.Sprintf("booster: On(%q, ...) handler must be func(Session, *T) error", name))
+	}
+
+	r.routes[name] = &route{
+		msgType: ft.In(1),
+		call: func(s Session, msg reflect.Value) error {
+			out := fv.Call([]reflect.Value{reflect.ValueOf(s), msg})
+			if err, ok := out[0].Interface().(error); ok {
+				return err
+			}
+			return nil
+		},
+	}
+}
+
+// dispatch decodes a frame and calls the handler registered for its name.
+func (r *Router) dispatch(s Session, data []byte) error {
+	name, payload, err := decodeFrame(data)
+	if err != nil {
+		return err
+	}
+
+	rt, ok := r.routes[name]
+	if !ok {
+		return fmt.Errorf("booster: no handler registered for %q", name)
+	}
+
+	msg := reflect.New(rt.msgType.Elem())
+	if err := r.codec.Unmarshal(payload, msg.Interface()); err != nil {
+		return err
+	}
+
+	return rt.call(s, msg)
+}
+
+// handle adapts dispatch to the handleMessageFunc signature, so it can be
+// installed as a Booster's messageHandler.
+func (r *Router) handle(s Session, data []byte) {
+	if err := r.dispatch(s, data); err != nil {
+		if r.booster.errorHandler != nil {
+			r.booster.errorHandler(s, err)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package booster
+
+import "testing"
+
+func TestEncodeDecodeFrame_RoundTrip(t *testing.T) {
+	frame := encodeFrame("greet", []byte(`{"hi":1}`))
+
+	name, payload, err := decodeFrame(frame)
+	if err != nil {
+		t.Fatalf("decodeFrame: %v", err)
+	}
+	if name != "greet" {
+		t.Fatalf("decodeFrame name = %q, want %q", name, "greet")
+	}
+	if string(payload) != `{"hi":1}` {
+		t.Fatalf("decodeFrame payload = %q, want %q", payload, `{"hi":1}`)
+	}
+}
+
+func TestDecodeFrame_MalformedHeader(t *testing.T) {
+	// A lone continuation byte (high bit set) is never a valid varint: it
+	// always leaves binary.Uvarint wanting more bytes than were given.
+	if _, _, err := decodeFrame([]byte{0x80}); err == nil {
+		t.Fatalf("decodeFrame with malformed varint header: got nil error, want non-nil")
+	}
+}
+
+func TestDecodeFrame_TruncatedFrame(t *testing.T) {
+	// Header claims a 10-byte name but only one byte follows.
+	data := append([]byte{10}, 'x')
+	if _, _, err := decodeFrame(data); err == nil {
+		t.Fatalf("decodeFrame with truncated name: got nil error, want non-nil")
+	}
+}
+
+func TestRouter_DispatchUnknownName(t *testing.T) {
+	b := NewBooster()
+	r := newRouter(b, JSONCodec{})
+
+	frame := encodeFrame("never-registered", []byte("{}"))
+	if err := r.dispatch(nil, frame); err == nil {
+		t.Fatalf("dispatch with unregistered name: got nil error, want non-nil")
+	}
+}
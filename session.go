@@ -2,16 +2,95 @@ package booster
 
 import (
 	"fmt"
-	"github.com/gorilla/websocket"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// A Session structure represents a remote peer
-type Session struct {
-	conn    *websocket.Conn
-	output  chan *envelope
+// Session represents a connected remote peer, whichever transport
+// (HandleWs, HandleSSE, ...) registered it.
+type Session interface {
+	// GetUserId returns the session's userId
+	GetUserId() string
+	// GetAppId returns the session's appId
+	GetAppId() string
+
+	// Set stores a key-val pair in the session
+	Set(key string, val interface{})
+	// Get returns val from the session by key
+	Get(key string) interface{}
+	// MustGet gets val from the session by key, panic if not found
+	MustGet(key string) interface{}
+	// GetString returns a string val from the session by key
+	GetString(key string) string
+	// GetInt returns a int val from the session by key
+	GetInt(key string) int
+	// GetInt64 returns a int64 val from the session by key
+	GetInt64(key string) int64
+
+	// GetParam returns a string from the http params
+	GetParam(key string) string
+	// GetParamInt returns a int from the http params, 0 if key not exist
+	GetParamInt(key string) int
+	// GetParamInt64 returns a int64 from the http params, 0 if key not exist
+	GetParamInt64(key string) int64
+
+	// Join adds the session to room, so PushToRoom can reach it.
+	Join(room string)
+	// Leave removes the session from room.
+	Leave(room string)
+	// Rooms returns every room the session has joined.
+	Rooms() []string
+
+	// Write writes msg to the session.
+	Write(msg []byte)
+	// WriteBinary writes a binary msg to the session.
+	WriteBinary(msg []byte)
+	// WriteTyped marshals msg through the Booster's Codec and writes it,
+	// framed under name, as text or binary depending on the Codec.
+	WriteTyped(name string, msg interface{}) error
+	// Close closes the session.
+	Close()
+
+	// Stats returns the session's current delivery counters.
+	Stats() Stats
+
+	// the rest is transport-specific plumbing used by Hub/Booster, and is
+	// implemented once, generically, by sessionCore; only writeRaw differs
+	// per transport.
+	writeMessage(message *envelope)
+	writeRaw(message *envelope) error
+	submit(message *envelope)
+	closeSlow()
+	closeOutput()
+	closeSendQueue()
+	waitExited()
+	waitWorkerExited()
+}
+
+// Stats reports a session's outbound delivery counters.
+type Stats struct {
+	// Dropped is how many messages this session's BackpressurePolicy has
+	// discarded because the output buffer was full.
+	Dropped uint64
+}
+
+// sessionCore is the transport-agnostic half of a Session: bookkeeping,
+// backpressure, rooms, and the custom key/value + http param stores.
+// A transport embeds it and only has to supply writeRaw; self must be set
+// to the embedding value so core methods can reach it.
+type sessionCore struct {
+	self Session
+
+	output chan *envelope
+
+	// sendQueue is the inbox submit feeds; a single worker goroutine drains
+	// it, so concurrent submitters (Hub.Run and Write/WriteBinary/Close
+	// callers alike) never race enqueue/closeSlow for this session or
+	// reorder what lands on output.
+	sendQueue chan *envelope
+
 	booster *Booster
 
 	userId string
@@ -23,160 +102,250 @@ type Session struct {
 	// params save input data from http request and SHOULD read only
 	params map[string]string
 
-	exited chan bool
+	// rooms is the set of rooms this session has joined, see Join/Leave.
+	rooms map[string]struct{}
+
+	// dropped and consecutiveDrops back Stats and the CloseSlow policy.
+	dropped          uint64
+	consecutiveDrops uint32
+
+	exited       chan bool
+	workerExited chan bool
 
 	sync.RWMutex
 }
 
-// Get session's userId
-func (s *Session) GetUserId() string {
+func (s *sessionCore) GetUserId() string {
 	return s.userId
 }
 
-// Get session's appId
-func (s *Session) GetAppId() string {
+func (s *sessionCore) GetAppId() string {
 	return s.appId
 }
 
-// Put message to WritePump
-func (s *Session) writeMessage(message *envelope) {
+func (s *sessionCore) Join(room string) {
+	s.Lock()
+	if s.rooms == nil {
+		s.rooms = make(map[string]struct{})
+	}
+	s.rooms[room] = struct{}{}
+	s.Unlock()
+
+	s.booster.getHub(s.appId).joinRoom <- &roomOp{session: s.self, room: room}
+}
+
+func (s *sessionCore) Leave(room string) {
+	s.Lock()
+	delete(s.rooms, room)
+	s.Unlock()
+
+	s.booster.getHub(s.appId).leaveRoom <- &roomOp{session: s.self, room: room}
+}
+
+func (s *sessionCore) Rooms() []string {
+	s.RLock()
+	defer s.RUnlock()
+
+	rooms := make([]string, 0, len(s.rooms))
+	for room := range s.rooms {
+		rooms = append(rooms, room)
+	}
+	return rooms
+}
+
+// Put message to this session's worker
+func (s *sessionCore) writeMessage(message *envelope) {
 	defer func() {
 		if err := recover(); err != nil {
 			fmt.Println(err)
 		}
 	}()
 
+	s.submit(message)
+}
+
+// submit hands message to this session's worker without blocking the
+// caller, whether that's Hub.Run (via Hub.send) or an application
+// goroutine (via Write/WriteBinary/Close): the worker is the only one
+// that ever calls enqueue/closeSlow, so sends for one session can't race
+// each other or land on output out of order. If the worker's own backlog
+// is full, message is dropped exactly as a full output buffer would be
+// under DropNewest.
+func (s *sessionCore) submit(message *envelope) {
 	select {
-	case s.output <- message:
+	case s.sendQueue <- message:
 	default:
-		s.booster.errorHandler(s, fmt.Errorf("write channel full, abandon message[%v]", message))
+		s.recordDrop()
 	}
 }
 
-// Send message to peer immediately
-func (s *Session) writeRaw(message *envelope) error {
-	s.conn.SetWriteDeadline(time.Now().Add(s.booster.config.WriteWait))
-	err := s.conn.WriteMessage(message.t, message.msg)
+// worker drains sendQueue one envelope at a time until it's closed, then
+// signals workerExited so closeOutput is safe to call (enqueue never sends
+// on output again once this returns).
+func (s *sessionCore) worker() {
+	for message := range s.sendQueue {
+		if s.enqueue(message) {
+			s.closeSlow()
+		}
+	}
+
+	s.workerExited <- true
+}
 
-	if err != nil {
-		return err
+// enqueue applies the Session's BackpressurePolicy to put message on
+// s.output, returning true under CloseSlow whenever the consecutive drops
+// have reached MaxConsecutiveDrops, telling the caller to close and
+// unregister the session.
+// Only worker calls this, so it never runs concurrently with itself for a
+// given session.
+func (s *sessionCore) enqueue(message *envelope) bool {
+	select {
+	case s.output <- message:
+		atomic.StoreUint32(&s.consecutiveDrops, 0)
+		return false
+	default:
 	}
 
-	if message.t == websocket.CloseMessage {
-		err := s.conn.Close()
+	switch s.booster.config.BackpressurePolicy {
+	case DropOldest:
+		select {
+		case <-s.output:
+		default:
+		}
 
-		if err != nil {
-			return err
+		select {
+		case s.output <- message:
+			atomic.StoreUint32(&s.consecutiveDrops, 0)
+			return false
+		default:
+		}
+
+	case BlockWithTimeout:
+		timer := time.NewTimer(s.booster.config.WriteEnqueueTimeout)
+		defer timer.Stop()
+
+		select {
+		case s.output <- message:
+			atomic.StoreUint32(&s.consecutiveDrops, 0)
+			return false
+		case <-timer.C:
 		}
 	}
 
-	return nil
+	return s.recordDrop()
 }
 
-// Send a close message to peer immediately
-func (s *Session) close() {
-	s.writeRaw(&envelope{t: websocket.CloseMessage, msg: []byte{}})
-}
+// recordDrop counts a dropped message and, under CloseSlow, reports whether
+// the consecutive drops have reached MaxConsecutiveDrops.
+func (s *sessionCore) recordDrop() bool {
+	atomic.AddUint64(&s.dropped, 1)
+	if s.booster.errorHandler != nil {
+		s.booster.errorHandler(s.self, fmt.Errorf("write channel full, abandon message"))
+	}
 
-// Send a ping message to peer immediately
-func (s *Session) ping() {
-	s.writeRaw(&envelope{t: websocket.PingMessage, msg: []byte{}})
-}
+	if s.booster.config.BackpressurePolicy != CloseSlow {
+		return false
+	}
 
-// WritePump async send message to remote peer
-func (s *Session) writePump() {
-	defer s.conn.Close()
+	return atomic.AddUint32(&s.consecutiveDrops, 1) >= uint32(s.booster.config.MaxConsecutiveDrops)
+}
 
-	ticker := time.NewTicker(s.booster.config.PingPeriod)
-	defer ticker.Stop()
+// closeSlow sends a close frame directly to the peer, bypassing the full
+// output buffer, then unregisters the session off the Hub's own goroutine
+// so one dead peer never wedges Hub.Run. writeRaw here runs on worker, not
+// writePump, so it can race writePump's own in-flight write; gorilla's
+// websocket panics rather than erroring on a concurrent write, so recover
+// instead of taking the whole process down over one bad peer.
+func (s *sessionCore) closeSlow() {
+	func() {
+		defer func() { recover() }()
+		s.self.writeRaw(&envelope{t: CloseMessage, msg: []byte{}})
+	}()
 
-loop:
-	for {
-		select {
-		case msg, ok := <-s.output:
-			if !ok {
-				s.close()
-				break loop
-			}
-
-			if err := s.writeRaw(msg); err != nil {
-				s.booster.errorHandler(s, err)
-				break loop
-			}
-
-		case <-ticker.C:
-			s.ping()
-		}
-	}
+	go func() {
+		s.booster.getHub(s.appId).unRegister <- s.self
+	}()
+}
 
-	s.exited <- true
+// closeOutput closes the session's output channel, waking its write pump.
+func (s *sessionCore) closeOutput() {
+	close(s.output)
 }
 
-// ReadPump receive message from remote peer, and turn message to messageHandler
-func (s *Session) readPump() {
-	defer s.conn.Close()
+// closeSendQueue closes the session's inbound send queue, stopping worker.
+func (s *sessionCore) closeSendQueue() {
+	close(s.sendQueue)
+}
 
-	s.conn.SetReadLimit(s.booster.config.MaxMessageSize)
+// waitExited blocks until the session's write pump has returned.
+func (s *sessionCore) waitExited() {
+	<-s.exited
+}
 
-	s.conn.SetPongHandler(func(string) error {
-		s.conn.SetReadDeadline(time.Now().Add(s.booster.config.PongWait))
-		return nil
-	})
+// waitWorkerExited blocks until the session's worker has drained
+// sendQueue and returned.
+func (s *sessionCore) waitWorkerExited() {
+	<-s.workerExited
+}
 
-	for {
-		s.conn.SetReadDeadline(time.Now().Add(s.booster.config.PongWait))
+// Stats returns the session's current delivery counters.
+func (s *sessionCore) Stats() Stats {
+	return Stats{Dropped: atomic.LoadUint64(&s.dropped)}
+}
 
-		t, message, err := s.conn.ReadMessage()
+// Write writes msg to the session.
+func (s *sessionCore) Write(msg []byte) {
+	s.self.writeMessage(&envelope{t: TextMessage, msg: msg})
+}
 
-		if err != nil {
-			s.booster.errorHandler(s, err)
-			break
-		}
+// WriteTyped marshals msg through the Booster's Codec, frames it under name
+// and writes it to the peer as text or binary depending on what the Codec
+// produces.
+func (s *sessionCore) WriteTyped(name string, msg interface{}) error {
+	payload, frameType, err := s.booster.router.codec.Marshal(msg)
+	if err != nil {
+		return err
+	}
 
-		if t == websocket.CloseMessage {
-			break
-		}
+	frame := encodeFrame(name, payload)
 
-		s.booster.messageHandler(s, message)
+	if frameType == BinaryMessage {
+		s.self.WriteBinary(frame)
+	} else {
+		s.self.Write(frame)
 	}
-}
 
-// Write message to session.
-func (s *Session) Write(msg []byte) {
-	s.writeMessage(&envelope{t: websocket.TextMessage, msg: msg})
+	return nil
 }
 
-// Write binary message to session.
-func (s *Session) WriteBinary(msg []byte) {
-	s.writeMessage(&envelope{t: websocket.BinaryMessage, msg: msg})
+// WriteBinary writes a binary msg to the session.
+func (s *sessionCore) WriteBinary(msg []byte) {
+	s.self.writeMessage(&envelope{t: BinaryMessage, msg: msg})
 }
 
-// Close session.
-func (s *Session) Close() {
-	s.writeMessage(&envelope{t: websocket.CloseMessage, msg: []byte{}})
+// Close closes the session.
+func (s *sessionCore) Close() {
+	s.self.writeMessage(&envelope{t: CloseMessage, msg: []byte{}})
 }
 
 // ---------------------------------------------------
 //             customized business data
 // ---------------------------------------------------
 
-// Store a key-val pair in session
-func (s *Session) Set(key string, val interface{}) {
+func (s *sessionCore) Set(key string, val interface{}) {
 	s.Lock()
 	s.keys[key] = val
 	s.Unlock()
 }
 
-// Get val from session by key
-func (s *Session) Get(key string) interface{} {
+func (s *sessionCore) Get(key string) interface{} {
 	s.RLock()
 	defer s.RUnlock()
 
 	return s.keys[key]
 }
 
-// Must get val from session by key, panic if not found
-func (s *Session) MustGet(key string) interface{} {
+func (s *sessionCore) MustGet(key string) interface{} {
 	val := s.Get(key)
 	if val == nil {
 		panic("Session MustGet:" + key + " fail")
@@ -184,8 +353,7 @@ func (s *Session) MustGet(key string) interface{} {
 	return val
 }
 
-// Get a string val from session by key
-func (s *Session) GetString(key string) string {
+func (s *sessionCore) GetString(key string) string {
 	val := s.Get(key)
 	if val != nil {
 		if v, ok := val.(string); ok {
@@ -196,8 +364,7 @@ func (s *Session) GetString(key string) string {
 	return ""
 }
 
-// Get a int val from session by key
-func (s *Session) GetInt(key string) int {
+func (s *sessionCore) GetInt(key string) int {
 	val := s.Get(key)
 	if val != nil {
 		if v, ok := val.(int); ok {
@@ -208,8 +375,7 @@ func (s *Session) GetInt(key string) int {
 	return 0
 }
 
-// Get a int64 val from session by key
-func (s *Session) GetInt64(key string) int64 {
+func (s *sessionCore) GetInt64(key string) int64 {
 	val := s.Get(key)
 	if val != nil {
 		if v, ok := val.(int64); ok {
@@ -224,13 +390,11 @@ func (s *Session) GetInt64(key string) int64 {
 //               http params
 // ---------------------------------------------------
 
-// GetParam returns a string from params
-func (s *Session) GetParam(key string) string {
+func (s *sessionCore) GetParam(key string) string {
 	return s.params[key]
 }
 
-// GetParamInt returns a int from params, returns 0 if key not exist
-func (s *Session) GetParamInt(key string) int {
+func (s *sessionCore) GetParamInt(key string) int {
 	val := s.GetParam(key)
 	if val == "" {
 		return 0
@@ -240,8 +404,7 @@ func (s *Session) GetParamInt(key string) int {
 	return n
 }
 
-// GetParamInt64 returns a int64 from params, returns 0 if key not exist
-func (s *Session) GetParamInt64(key string) int64 {
+func (s *sessionCore) GetParamInt64(key string) int64 {
 	val := s.GetParam(key)
 	if val == "" {
 		return 0
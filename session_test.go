@@ -0,0 +1,58 @@
+package booster
+
+import "testing"
+
+// testSession is a minimal Session for exercising sessionCore's backpressure
+// logic without a real websocket/SSE transport: writeRaw just records what
+// it was asked to send.
+type testSession struct {
+	sessionCore
+
+	writes []*envelope
+}
+
+func newTestSession(b *Booster, bufSize int) *testSession {
+	s := &testSession{
+		sessionCore: sessionCore{
+			output:       make(chan *envelope, bufSize),
+			sendQueue:    make(chan *envelope, bufSize),
+			booster:      b,
+			appId:        "app",
+			userId:       "user",
+			keys:         make(map[string]interface{}),
+			params:       make(map[string]string),
+			exited:       make(chan bool, 1),
+			workerExited: make(chan bool, 1),
+		},
+	}
+	s.self = s
+	go s.worker()
+
+	return s
+}
+
+func (s *testSession) writeRaw(message *envelope) error {
+	s.writes = append(s.writes, message)
+	return nil
+}
+
+func TestSessionCore_DropWithoutErrorHandlerDoesNotPanic(t *testing.T) {
+	b := NewBooster() // no HandleError registered
+	s := newTestSession(b, 1)
+	t.Cleanup(func() { s.closeSendQueue() })
+
+	if closeNow := s.enqueue(&envelope{t: TextMessage, msg: []byte("1")}); closeNow {
+		t.Fatalf("first enqueue has room, should not ask to close the session")
+	}
+
+	// The buffer is now full. Under the default DropNewest policy this used
+	// to call errorHandler unconditionally, panicking Hub.Run's own
+	// goroutine when no HandleError was registered.
+	if closeNow := s.enqueue(&envelope{t: TextMessage, msg: []byte("2")}); closeNow {
+		t.Fatalf("DropNewest never asks to close the session")
+	}
+
+	if got := s.Stats().Dropped; got != 1 {
+		t.Fatalf("Stats().Dropped = %d, want 1", got)
+	}
+}
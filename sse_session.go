@@ -0,0 +1,107 @@
+package booster
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// sseSession is the Server-Sent-Events implementation of Session. It has no
+// read pump: a peer receives pushes over the stream but cannot write back
+// on the same connection.
+type sseSession struct {
+	sessionCore
+
+	w       http.ResponseWriter
+	flusher http.Flusher
+	ctx     context.Context
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newSseSession(b *Booster, w http.ResponseWriter, flusher http.Flusher, ctx context.Context, appId, userId string, params map[string]string) *sseSession {
+	s := &sseSession{
+		sessionCore: sessionCore{
+			output:       make(chan *envelope, b.config.MessageBufferSize),
+			sendQueue:    make(chan *envelope, b.config.MessageBufferSize),
+			booster:      b,
+			appId:        appId,
+			userId:       userId,
+			keys:         make(map[string]interface{}),
+			params:       params,
+			exited:       make(chan bool),
+			workerExited: make(chan bool),
+		},
+		w:       w,
+		flusher: flusher,
+		ctx:     ctx,
+		closed:  make(chan struct{}),
+	}
+	s.self = s
+
+	return s
+}
+
+// writeRaw flushes message to the peer as one SSE frame. A CloseMessage
+// carries no payload of its own; it tells writePump to end the stream.
+//
+// message.msg is base64-encoded before it goes on the wire: SSE frames a
+// "data:" line per newline, so any payload containing '\n' (arbitrary
+// binary, or even just pretty-printed JSON) would otherwise be split
+// across lines and truncated by the client. Peers must base64-decode data
+// to recover message.msg.
+func (s *sseSession) writeRaw(message *envelope) error {
+	if message.t == CloseMessage {
+		s.closeOnce.Do(func() { close(s.closed) })
+		return nil
+	}
+
+	encoded := base64.StdEncoding.EncodeToString(message.msg)
+	if _, err := fmt.Fprintf(s.w, "event: message\ndata: %s\n\n", encoded); err != nil {
+		return err
+	}
+
+	s.flusher.Flush()
+	return nil
+}
+
+// writePump flushes envelopes to the peer as SSE frames until the session
+// is closed or the peer goes away, sending a ": keepalive" comment on every
+// tick in place of a websocket ping.
+func (s *sseSession) writePump() {
+	ticker := time.NewTicker(s.booster.config.PingPeriod)
+	defer ticker.Stop()
+
+loop:
+	for {
+		select {
+		case msg, ok := <-s.output:
+			if !ok {
+				break loop
+			}
+
+			if err := s.writeRaw(msg); err != nil {
+				if s.booster.errorHandler != nil {
+					s.booster.errorHandler(s, err)
+				}
+				break loop
+			}
+
+		case <-ticker.C:
+			fmt.Fprint(s.w, ": keepalive\n\n")
+			s.flusher.Flush()
+
+		case <-s.closed:
+			break loop
+
+		case <-s.ctx.Done():
+			break loop
+		}
+	}
+
+	s.exited <- true
+}
@@ -0,0 +1,57 @@
+package booster
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// flushRecorder adapts httptest.ResponseRecorder, which doesn't implement
+// http.Flusher, so it can stand in for the real http.ResponseWriter newSseSession expects.
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (f *flushRecorder) Flush() {}
+
+func TestSseSession_WriteRaw_Base64EncodesMultilinePayload(t *testing.T) {
+	w := &flushRecorder{httptest.NewRecorder()}
+	b := NewBooster()
+
+	s := newSseSession(b, w, w, context.Background(), "app", "user", nil)
+
+	payload := []byte("line1\nline2")
+	if err := s.writeRaw(&envelope{t: TextMessage, msg: payload}); err != nil {
+		t.Fatalf("writeRaw: %v", err)
+	}
+
+	body := w.Body.String()
+
+	// Every line written to the stream must either be the "event:" line, a
+	// "data:"-prefixed line, or blank: a bare continuation line is what
+	// silently truncates the message for EventSource clients.
+	for _, line := range strings.Split(strings.TrimRight(body, "\n"), "\n") {
+		if line == "" || strings.HasPrefix(line, "event:") || strings.HasPrefix(line, "data:") {
+			continue
+		}
+		t.Fatalf("writeRaw wrote bare line %q outside a data: field: %q", line, body)
+	}
+
+	const prefix = "data: "
+	idx := strings.Index(body, prefix)
+	if idx < 0 {
+		t.Fatalf("writeRaw output has no data: field: %q", body)
+	}
+	encoded := body[idx+len(prefix):]
+	encoded = encoded[:strings.IndexByte(encoded, '\n')]
+
+	decoded, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		t.Fatalf("data: field is not valid base64: %v", err)
+	}
+	if string(decoded) != string(payload) {
+		t.Fatalf("decoded payload = %q, want %q", decoded, payload)
+	}
+}
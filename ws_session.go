@@ -0,0 +1,125 @@
+package booster
+
+import (
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsSession is the websocket implementation of Session.
+type wsSession struct {
+	sessionCore
+
+	conn *websocket.Conn
+}
+
+func newWsSession(b *Booster, conn *websocket.Conn, appId, userId string, params map[string]string) *wsSession {
+	s := &wsSession{
+		sessionCore: sessionCore{
+			output:       make(chan *envelope, b.config.MessageBufferSize),
+			sendQueue:    make(chan *envelope, b.config.MessageBufferSize),
+			booster:      b,
+			appId:        appId,
+			userId:       userId,
+			keys:         make(map[string]interface{}),
+			params:       params,
+			exited:       make(chan bool),
+			workerExited: make(chan bool),
+		},
+		conn: conn,
+	}
+	s.self = s
+
+	return s
+}
+
+// Send message to peer immediately
+func (s *wsSession) writeRaw(message *envelope) error {
+	s.conn.SetWriteDeadline(time.Now().Add(s.booster.config.WriteWait))
+	err := s.conn.WriteMessage(message.t, message.msg)
+
+	if err != nil {
+		return err
+	}
+
+	if message.t == CloseMessage {
+		if err := s.conn.Close(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Send a close message to peer immediately
+func (s *wsSession) close() {
+	s.writeRaw(&envelope{t: CloseMessage, msg: []byte{}})
+}
+
+// Send a ping message to peer immediately
+func (s *wsSession) ping() {
+	s.writeRaw(&envelope{t: PingMessage, msg: []byte{}})
+}
+
+// writePump async send message to remote peer
+func (s *wsSession) writePump() {
+	defer s.conn.Close()
+
+	ticker := time.NewTicker(s.booster.config.PingPeriod)
+	defer ticker.Stop()
+
+loop:
+	for {
+		select {
+		case msg, ok := <-s.output:
+			if !ok {
+				s.close()
+				break loop
+			}
+
+			if err := s.writeRaw(msg); err != nil {
+				if s.booster.errorHandler != nil {
+					s.booster.errorHandler(s, err)
+				}
+				break loop
+			}
+
+		case <-ticker.C:
+			s.ping()
+		}
+	}
+
+	s.exited <- true
+}
+
+// readPump receives messages from the remote peer and turns them into
+// messageHandler calls
+func (s *wsSession) readPump() {
+	defer s.conn.Close()
+
+	s.conn.SetReadLimit(s.booster.config.MaxMessageSize)
+
+	s.conn.SetPongHandler(func(string) error {
+		s.conn.SetReadDeadline(time.Now().Add(s.booster.config.PongWait))
+		return nil
+	})
+
+	for {
+		s.conn.SetReadDeadline(time.Now().Add(s.booster.config.PongWait))
+
+		t, message, err := s.conn.ReadMessage()
+
+		if err != nil {
+			if s.booster.errorHandler != nil {
+				s.booster.errorHandler(s, err)
+			}
+			break
+		}
+
+		if t == websocket.CloseMessage {
+			break
+		}
+
+		s.booster.messageHandler(s, message)
+	}
+}